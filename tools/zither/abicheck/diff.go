@@ -0,0 +1,238 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package abicheck compares two IFS (Interface Stub) files - as produced by
+// the zither `zircon_ifs` backend - and reports the ABI differences between
+// them. It underlies zither's `-mode=verify`, which uses it to catch
+// breaking ABI changes (removed symbols, changed signatures, ordinal drift)
+// before they land.
+package abicheck
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Symbol is a single exported ABI entry, as recorded in an IFS file: a
+// symbol name together with the signature (type/size/version information)
+// IFS associates with it.
+type Symbol struct {
+	Name      string
+	Signature string
+}
+
+// Manifest is the set of symbols recorded in an IFS file.
+type Manifest struct {
+	Symbols map[string]Symbol
+}
+
+// ParseIFS parses the IFS (Interface Stub) file at path into a Manifest.
+//
+// IFS, a.k.a. TextAPI, is the YAML-based ELF stub format emitted by
+// `llvm-ifs` and, for zither's purposes, its `zircon_ifs` backend:
+//
+//	--- !ifs-v1
+//	IfsVersion: 3.0
+//	Target: x86_64-unknown-fuchsia
+//	NeededLibs:
+//	  - libzircon.so
+//	Symbols:
+//	  - Name: zx_channel_create
+//	    Type:  Func
+//	  - Name: zx_channel_write
+//	    Type:  Func
+//
+// This is a light-weight, line-oriented parser that understands just enough
+// of that format for zither's ABI-stability check: the top-level `Symbols:`
+// list, each of whose `- Name: ...` entries may spread its remaining fields
+// (`Type:`, or anything else `zircon_ifs` emits) across the following more-
+// indented lines, or inline on the same line as braces (`- { Name: ...,
+// Type: ... }`). Everything else at the top level (`IfsVersion:`, `Target:`,
+// `NeededLibs:`, ...) is skipped. It is not a general YAML parser and is not
+// meant to replace `llvm-ifs` for anything but this check.
+func ParseIFS(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("abicheck: %w", err)
+	}
+	defer f.Close()
+
+	m, err := parseIFS(f)
+	if err != nil {
+		return nil, fmt.Errorf("abicheck: reading %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func parseIFS(r io.Reader) (*Manifest, error) {
+	m := &Manifest{Symbols: make(map[string]Symbol)}
+
+	var (
+		inSymbols     bool
+		symbolsIndent int
+		fields        map[string]string
+		fieldOrder    []string
+	)
+
+	flush := func() {
+		if fields == nil {
+			return
+		}
+		name, ok := fields["Name"]
+		if ok {
+			var parts []string
+			for _, k := range fieldOrder {
+				if k == "Name" {
+					continue
+				}
+				parts = append(parts, k+": "+fields[k])
+			}
+			m.Symbols[name] = Symbol{Name: name, Signature: strings.Join(parts, ", ")}
+		}
+		fields = nil
+		fieldOrder = nil
+	}
+
+	setField := func(key, value string) {
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		if _, ok := fields[key]; !ok {
+			fieldOrder = append(fieldOrder, key)
+		}
+		fields[key] = value
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+
+		if !inSymbols {
+			if trimmed == "Symbols:" {
+				inSymbols = true
+				symbolsIndent = indent
+			}
+			continue
+		}
+
+		if indent <= symbolsIndent {
+			flush()
+			inSymbols = trimmed == "Symbols:"
+			if inSymbols {
+				symbolsIndent = indent
+			}
+			continue
+		}
+
+		if entry := strings.TrimPrefix(trimmed, "-"); entry != trimmed {
+			flush()
+			entry = strings.TrimSpace(entry)
+			entry = strings.TrimPrefix(entry, "{")
+			entry = strings.TrimSuffix(entry, "}")
+			for _, kv := range strings.Split(entry, ",") {
+				if key, value, ok := strings.Cut(kv, ":"); ok {
+					key = strings.TrimSpace(key)
+					if key != "" {
+						setField(key, strings.TrimSpace(value))
+					}
+				}
+			}
+			continue
+		}
+
+		if fields != nil {
+			if key, value, ok := strings.Cut(trimmed, ":"); ok {
+				setField(strings.TrimSpace(key), strings.TrimSpace(value))
+			}
+		}
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ChangeKind classifies a single symbol-level difference between two
+// manifests.
+type ChangeKind string
+
+const (
+	// Added means the symbol is present in the new manifest but not the old
+	// one. This is never breaking.
+	Added ChangeKind = "added"
+
+	// Removed means the symbol is present in the old manifest but not the
+	// new one. This is always breaking.
+	Removed ChangeKind = "removed"
+
+	// Changed means the symbol is present in both, but its signature
+	// differs. This is breaking, as it indicates the symbol's calling
+	// convention or layout changed underneath existing callers.
+	Changed ChangeKind = "changed"
+)
+
+// Change describes one symbol's difference between an old and new manifest.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	Name string     `json:"name"`
+	Old  string     `json:"old,omitempty"`
+	New  string     `json:"new,omitempty"`
+}
+
+// Breaking reports whether kind represents a breaking ABI change.
+func (c Change) Breaking() bool {
+	return c.Kind == Removed || c.Kind == Changed
+}
+
+// Report is the structured summary of comparing two manifests, suitable for
+// serialization to `-output-manifest` for CI gating.
+type Report struct {
+	Breaking bool     `json:"breaking"`
+	Changes  []Change `json:"changes"`
+}
+
+// Compare diffs old against new, returning a Report of every added, removed,
+// or changed symbol. Ordinal drift - a symbol's ordinal changing between
+// versions even though its name and signature are stable - is surfaced as a
+// Changed entry, since IFS signatures are expected to encode ordinal.
+func Compare(old, new *Manifest) Report {
+	var changes []Change
+	for name, oldSym := range old.Symbols {
+		newSym, ok := new.Symbols[name]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Name: name, Old: oldSym.Signature})
+			continue
+		}
+		if oldSym.Signature != newSym.Signature {
+			changes = append(changes, Change{Kind: Changed, Name: name, Old: oldSym.Signature, New: newSym.Signature})
+		}
+	}
+	for name, newSym := range new.Symbols {
+		if _, ok := old.Symbols[name]; !ok {
+			changes = append(changes, Change{Kind: Added, Name: name, New: newSym.Signature})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+
+	report := Report{Changes: changes}
+	for _, c := range changes {
+		if c.Breaking() {
+			report.Breaking = true
+			break
+		}
+	}
+	return report
+}
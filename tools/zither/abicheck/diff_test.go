@@ -0,0 +1,245 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package abicheck
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func writeIFS(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+	return path
+}
+
+func TestParseIFS(t *testing.T) {
+	dir := t.TempDir()
+	// Exercises both field layouts zircon_ifs may emit for a symbol entry:
+	// fields spread across indented continuation lines (zx_channel_create)
+	// and fields given inline in brace form (zx_channel_write). The
+	// IfsVersion/Target/NeededLibs header keys, a comment, and a blank line
+	// should all be skipped as non-symbol content.
+	path := writeIFS(t, dir, "test.ifs", `--- !ifs-v1
+IfsVersion: 3.0
+Target: x86_64-unknown-fuchsia
+# a comment line, and a blank line below should both be skipped
+
+NeededLibs:
+  - libzircon.so
+Symbols:
+  - Name: zx_channel_create
+    Type:    Func
+    Ordinal: 1
+  - { Name: zx_channel_write, Type: Func, Ordinal: 2 }
+`)
+
+	m, err := ParseIFS(path)
+	if err != nil {
+		t.Fatalf("ParseIFS(%s) failed: %s", path, err)
+	}
+
+	want := map[string]Symbol{
+		"zx_channel_create": {Name: "zx_channel_create", Signature: "Type: Func, Ordinal: 1"},
+		"zx_channel_write":  {Name: "zx_channel_write", Signature: "Type: Func, Ordinal: 2"},
+	}
+	if !reflect.DeepEqual(m.Symbols, want) {
+		t.Errorf("ParseIFS(%s) = %#v, want %#v", path, m.Symbols, want)
+	}
+}
+
+func TestParseIFSNoSymbols(t *testing.T) {
+	dir := t.TempDir()
+	path := writeIFS(t, dir, "empty.ifs", `--- !ifs-v1
+IfsVersion: 3.0
+Target: x86_64-unknown-fuchsia
+NeededLibs:
+  - libzircon.so
+`)
+
+	m, err := ParseIFS(path)
+	if err != nil {
+		t.Fatalf("ParseIFS(%s) failed: %s", path, err)
+	}
+	if len(m.Symbols) != 0 {
+		t.Errorf("ParseIFS(%s) with no Symbols: section = %#v, want empty", path, m.Symbols)
+	}
+}
+
+func TestParseIFSMissing(t *testing.T) {
+	if _, err := ParseIFS(filepath.Join(t.TempDir(), "nonexistent.ifs")); err == nil {
+		t.Error("ParseIFS of a nonexistent file: expected an error, got nil")
+	}
+}
+
+// TestParseIFSThenCompareDetectsOrdinalDrift drives ParseIFS and Compare
+// together against two realistic zircon_ifs-shaped IFS files that differ
+// only in one symbol's Ordinal field, end to end - the same path runVerify
+// in cmd/main.go takes - to confirm ordinal drift is actually caught when
+// starting from real IFS text, not just from Manifests built by hand.
+func TestParseIFSThenCompareDetectsOrdinalDrift(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := writeIFS(t, dir, "old.ifs", `--- !ifs-v1
+IfsVersion: 3.0
+Target: x86_64-unknown-fuchsia
+Symbols:
+  - Name: zx_channel_create
+    Type:    Func
+    Ordinal: 1
+  - Name: zx_channel_write
+    Type:    Func
+    Ordinal: 2
+`)
+	newPath := writeIFS(t, dir, "new.ifs", `--- !ifs-v1
+IfsVersion: 3.0
+Target: x86_64-unknown-fuchsia
+Symbols:
+  - Name: zx_channel_create
+    Type:    Func
+    Ordinal: 1
+  - Name: zx_channel_write
+    Type:    Func
+    Ordinal: 3
+`)
+
+	oldManifest, err := ParseIFS(oldPath)
+	if err != nil {
+		t.Fatalf("ParseIFS(%s) failed: %s", oldPath, err)
+	}
+	newManifest, err := ParseIFS(newPath)
+	if err != nil {
+		t.Fatalf("ParseIFS(%s) failed: %s", newPath, err)
+	}
+
+	report := Compare(oldManifest, newManifest)
+	if !report.Breaking {
+		t.Errorf("Compare() across a real ordinal-drift IFS pair: Breaking = false, want true")
+	}
+	want := []Change{{
+		Kind: Changed,
+		Name: "zx_channel_write",
+		Old:  "Type: Func, Ordinal: 2",
+		New:  "Type: Func, Ordinal: 3",
+	}}
+	if got := sortedChanges(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() Changes = %#v, want %#v", got, want)
+	}
+}
+
+func manifest(symbols ...Symbol) *Manifest {
+	m := &Manifest{Symbols: make(map[string]Symbol)}
+	for _, s := range symbols {
+		m.Symbols[s.Name] = s
+	}
+	return m
+}
+
+func sortedChanges(report Report) []Change {
+	changes := append([]Change(nil), report.Changes...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func TestCompareNoChanges(t *testing.T) {
+	old := manifest(Symbol{Name: "zx_clock_get", Signature: "{Type: Func, Ordinal: 1}"})
+	new := manifest(Symbol{Name: "zx_clock_get", Signature: "{Type: Func, Ordinal: 1}"})
+
+	report := Compare(old, new)
+	if report.Breaking {
+		t.Errorf("Compare() of identical manifests: Breaking = true, want false")
+	}
+	if len(report.Changes) != 0 {
+		t.Errorf("Compare() of identical manifests: Changes = %#v, want empty", report.Changes)
+	}
+}
+
+func TestCompareAdded(t *testing.T) {
+	old := manifest()
+	new := manifest(Symbol{Name: "zx_clock_get", Signature: "{Type: Func, Ordinal: 1}"})
+
+	report := Compare(old, new)
+	if report.Breaking {
+		t.Errorf("Compare() with only an added symbol: Breaking = true, want false")
+	}
+	want := []Change{{Kind: Added, Name: "zx_clock_get", New: "{Type: Func, Ordinal: 1}"}}
+	if got := sortedChanges(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() Changes = %#v, want %#v", got, want)
+	}
+}
+
+func TestCompareRemoved(t *testing.T) {
+	old := manifest(Symbol{Name: "zx_clock_get", Signature: "{Type: Func, Ordinal: 1}"})
+	new := manifest()
+
+	report := Compare(old, new)
+	if !report.Breaking {
+		t.Errorf("Compare() with a removed symbol: Breaking = false, want true")
+	}
+	want := []Change{{Kind: Removed, Name: "zx_clock_get", Old: "{Type: Func, Ordinal: 1}"}}
+	if got := sortedChanges(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() Changes = %#v, want %#v", got, want)
+	}
+}
+
+// TestCompareOrdinalDrift exercises the case Compare's doc comment calls out
+// by name: a symbol whose name and calling convention are otherwise stable,
+// but whose IFS-encoded ordinal has changed between the old and new
+// manifest. Since IFS signature strings are expected to encode ordinal, this
+// must surface as a Changed (and therefore breaking) entry, not be silently
+// missed.
+func TestCompareOrdinalDrift(t *testing.T) {
+	old := manifest(Symbol{Name: "zx_channel_write", Signature: "{Type: Func, Ordinal: 42}"})
+	new := manifest(Symbol{Name: "zx_channel_write", Signature: "{Type: Func, Ordinal: 43}"})
+
+	report := Compare(old, new)
+	if !report.Breaking {
+		t.Errorf("Compare() with an ordinal-only change: Breaking = false, want true")
+	}
+	want := []Change{{
+		Kind: Changed,
+		Name: "zx_channel_write",
+		Old:  "{Type: Func, Ordinal: 42}",
+		New:  "{Type: Func, Ordinal: 43}",
+	}}
+	if got := sortedChanges(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() Changes = %#v, want %#v", got, want)
+	}
+	if !want[0].Breaking() {
+		t.Errorf("Change.Breaking() for an ordinal drift Changed entry = false, want true")
+	}
+}
+
+func TestCompareMixed(t *testing.T) {
+	old := manifest(
+		Symbol{Name: "zx_clock_get", Signature: "{Type: Func, Ordinal: 1}"},
+		Symbol{Name: "zx_channel_write", Signature: "{Type: Func, Ordinal: 2}"},
+	)
+	new := manifest(
+		Symbol{Name: "zx_clock_get", Signature: "{Type: Func, Ordinal: 1}"},
+		Symbol{Name: "zx_channel_write", Signature: "{Type: Func, Ordinal: 3}"},
+		Symbol{Name: "zx_channel_read", Signature: "{Type: Func, Ordinal: 4}"},
+	)
+
+	report := Compare(old, new)
+	if !report.Breaking {
+		t.Errorf("Compare() with a changed symbol present: Breaking = false, want true")
+	}
+	want := []Change{
+		{Kind: Changed, Name: "zx_channel_write", Old: "{Type: Func, Ordinal: 2}", New: "{Type: Func, Ordinal: 3}"},
+		{Kind: Added, Name: "zx_channel_read", New: "{Type: Func, Ordinal: 4}"},
+	}
+	got := report.Changes
+	sort.Slice(want, func(i, j int) bool { return want[i].Name < want[j].Name })
+	sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() Changes = %#v, want %#v", got, want)
+	}
+}
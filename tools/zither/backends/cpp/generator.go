@@ -0,0 +1,163 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package cpp provides a zither backend for generating C++ data-layout
+// bindings: namespaced headers defining `constexpr` constants, `enum class`
+// enums, strongly-typed bitmask types, type aliases, and structs laid out to
+// match the associated FIDL wire format.
+package cpp
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	fidlgencpp "go.fuchsia.dev/fuchsia/tools/fidl/lib/fidlgen_cpp"
+	"go.fuchsia.dev/fuchsia/tools/fidl/lib/fidlgen"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends"
+)
+
+func init() {
+	backends.Register("cpp", func(f fidlgen.Formatter, namespace string) (backends.Generator, bool) {
+		return NewGenerator(f, namespace), true
+	})
+}
+
+// Generator provides a zither backend that generates C++ bindings.
+type Generator struct {
+	fidlgen.Formatter
+
+	namespace string
+}
+
+// NewGenerator creates a new C++ backend generator. namespace, if non-empty,
+// overrides the default C++ namespace (derived from the FIDL library name).
+func NewGenerator(formatter fidlgen.Formatter, namespace string) *Generator {
+	return &Generator{Formatter: formatter, namespace: namespace}
+}
+
+// DeclOrder gives the declaration order desired by the C++ backend: types
+// must be declared before they are referenced, as C++ has no notion of
+// forward declaration for the aggregate types zither emits.
+func (gen *Generator) DeclOrder() zither.DeclOrder {
+	return zither.DependencyDeclOrder
+}
+
+// DeclCallback is a no-op for this backend, which does not need to collect
+// any additional state across the declarations it is handed.
+func (gen *Generator) DeclCallback(zither.Decl) {}
+
+// Generate generates the C++ bindings for summary, emitting a single,
+// self-contained header into outputDir.
+func (gen *Generator) Generate(summary zither.LibrarySummary, outputDir string) ([]string, error) {
+	namespace := gen.namespace
+	if namespace == "" {
+		namespace = cppNamespace(summary.Library)
+	}
+
+	relOutput := filepath.Join(strings.ReplaceAll(summary.Library.String(), ".", "/"), "data-layout.h")
+	output := filepath.Join(outputDir, relOutput)
+
+	var text strings.Builder
+	if err := headerTemplate.Execute(&text, struct {
+		zither.LibrarySummary
+		Namespace  string
+		IncludeTag string
+	}{
+		LibrarySummary: summary,
+		Namespace:      namespace,
+		IncludeTag:     strings.ToUpper(strings.ReplaceAll(relOutput, "/", "_")) + "_",
+	}); err != nil {
+		return nil, err
+	}
+
+	formatted, err := gen.Format([]byte(text.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to format %s: %w", output, err)
+	}
+
+	if err := zither.WriteFile(output, formatted); err != nil {
+		return nil, err
+	}
+	return []string{relOutput}, nil
+}
+
+// cppNamespace derives the default C++ namespace from a FIDL library name,
+// mirroring the convention used by fidlgen_cpp for high-level bindings.
+func cppNamespace(library fidlgen.LibraryName) string {
+	return fidlgencpp.CppNamespace(library).String()
+}
+
+var headerTemplate = template.Must(template.New("cppDataLayout").Parse(`// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+//
+// Generated from FIDL library {{.Library}} by zither; DO NOT EDIT.
+
+#ifndef {{.IncludeTag}}
+#define {{.IncludeTag}}
+
+#include <cstdint>
+
+namespace {{.Namespace}} {
+
+{{range .Constants}}
+constexpr auto {{.Name}} = {{.Value}};
+{{- end}}
+
+{{range .Aliases}}
+using {{.Name}} = {{.Type}};
+{{- end}}
+
+{{range .Enums}}
+enum class {{.Name}} : {{.Subtype}} {
+{{- range .Members}}
+  k{{.Name}} = {{.Value}},
+{{- end}}
+};
+{{- end}}
+
+{{range .Bits}}
+enum class {{.Name}} : {{.Subtype}} {
+{{- range .Members}}
+  k{{.Name}} = {{.Value}},
+{{- end}}
+};
+constexpr {{.Name}} operator|({{.Name}} lhs, {{.Name}} rhs) {
+  return static_cast<{{.Name}}>(static_cast<{{.Subtype}}>(lhs) | static_cast<{{.Subtype}}>(rhs));
+}
+constexpr {{.Name}} operator&({{.Name}} lhs, {{.Name}} rhs) {
+  return static_cast<{{.Name}}>(static_cast<{{.Subtype}}>(lhs) & static_cast<{{.Subtype}}>(rhs));
+}
+constexpr {{.Name}} operator^({{.Name}} lhs, {{.Name}} rhs) {
+  return static_cast<{{.Name}}>(static_cast<{{.Subtype}}>(lhs) ^ static_cast<{{.Subtype}}>(rhs));
+}
+constexpr {{.Name}} operator~({{.Name}} val) {
+  return static_cast<{{.Name}}>(~static_cast<{{.Subtype}}>(val));
+}
+constexpr {{.Name}}& operator|=({{.Name}}& lhs, {{.Name}} rhs) {
+  return lhs = lhs | rhs;
+}
+constexpr {{.Name}}& operator&=({{.Name}}& lhs, {{.Name}} rhs) {
+  return lhs = lhs & rhs;
+}
+constexpr {{.Name}}& operator^=({{.Name}}& lhs, {{.Name}} rhs) {
+  return lhs = lhs ^ rhs;
+}
+{{- end}}
+
+{{range .Structs}}
+struct {{.Name}} {
+{{- range .Members}}
+  {{.Type}} {{.Name}};
+{{- end}}
+};
+{{- end}}
+
+}  // namespace {{.Namespace}}
+
+#endif  // {{.IncludeTag}}
+`))
@@ -0,0 +1,224 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package extplugin lets zither load an out-of-tree backend as a separate
+// process, speaking a small JSON-over-stdio protocol, rather than as a Go
+// `plugin.Plugin` (whose .so-based implementation is unavailable on several
+// of the GOOS values zither itself is built for). A `-backend-plugin` value
+// is the path to an executable implementing this protocol.
+//
+// The protocol is a sequence of newline-delimited JSON requests on the
+// plugin's stdin, each answered by exactly one newline-delimited JSON
+// response on its stdout:
+//
+//	-> {"op":"decl_order"}
+//	<- {"decl_order":"source"|"dependency"}
+//
+//	-> {"op":"decl_callback","kind":"const"|"alias"|"enum"|"bits"|"struct"|"protocol","decl":<zither.Decl>}
+//	<- {}
+//
+//	-> {"op":"generate","summary":<zither.LibrarySummary>,"output_dir":"..."}
+//	<- {"outputs":["..."]} | {"error":"..."}
+//
+// zither.Decl itself carries no type discriminator - it is a marker
+// interface implemented by zither.Const, zither.Alias, zither.Enum,
+// zither.Bits, zither.Struct, and zither.Protocol - so decl_callback's
+// "kind" field identifies which of those the accompanying "decl" payload
+// is, letting a plugin decode it into the matching shape (i.e. the JSON
+// field names and types of the Go struct named by kind).
+package extplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+)
+
+// closeWait is the longest this package will wait, after closing a plugin's
+// stdin, for the plugin process to exit on its own before killing it. A
+// well-behaved plugin should exit promptly once its stdin is closed; this
+// bounds the damage a plugin that ignores EOF on stdin (hangs, deadlocks,
+// awaits further input that will never come) can do to the overall zither
+// invocation.
+const closeWait = 5 * time.Second
+
+// Generator is a zither backends.Generator backed by a plugin subprocess.
+type Generator struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	enc *json.Encoder
+	dec *json.Decoder
+
+	declOrder zither.DeclOrder
+}
+
+// Load starts the plugin executable at path and performs the protocol's
+// initial handshake, querying the plugin's desired declaration order.
+func Load(path string) (*Generator, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("extplugin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("extplugin: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("extplugin: starting %s: %w", path, err)
+	}
+
+	g := &Generator{
+		cmd: cmd,
+		in:  stdin,
+		enc: json.NewEncoder(stdin),
+		dec: json.NewDecoder(bufio.NewReader(stdout)),
+	}
+
+	var resp response
+	if err := g.roundTrip(request{Op: opDeclOrder}, &resp); err != nil {
+		g.kill()
+		return nil, fmt.Errorf("extplugin: %s: %w", opDeclOrder, err)
+	}
+	g.declOrder = zither.DeclOrder(resp.DeclOrder)
+	return g, nil
+}
+
+// kill forcibly terminates the plugin subprocess and reaps it, discarding
+// any error: used when the protocol handshake itself fails, so Load doesn't
+// leak a misbehaving process that it never handed a *Generator back for the
+// caller to Close.
+func (g *Generator) kill() {
+	g.in.Close()
+	if g.cmd.Process != nil {
+		_ = g.cmd.Process.Kill()
+	}
+	_ = g.cmd.Wait()
+}
+
+// DeclOrder gives the declaration order reported by the plugin.
+func (g *Generator) DeclOrder() zither.DeclOrder {
+	return g.declOrder
+}
+
+// DeclCallback forwards decl to the plugin, tagged with its declKind so the
+// plugin can tell which concrete decl type it was sent.
+func (g *Generator) DeclCallback(decl zither.Decl) {
+	kind, err := kindOf(decl)
+	if err != nil {
+		// As below, DeclCallback has no error return to surface this through.
+		panic(fmt.Sprintf("extplugin: %s", err))
+	}
+
+	var resp response
+	if err := g.roundTrip(request{Op: opDeclCallback, Kind: kind, Decl: decl}, &resp); err != nil {
+		// DeclCallback has no error return in the zither.Summarize() contract
+		// it fulfills; a plugin protocol failure here is unrecoverable, so
+		// surface it the same way a panicking backend callback would.
+		panic(fmt.Sprintf("extplugin: %s: %s", opDeclCallback, err))
+	}
+}
+
+// Generate asks the plugin to generate bindings for summary into outputDir.
+func (g *Generator) Generate(summary zither.LibrarySummary, outputDir string) ([]string, error) {
+	var resp response
+	req := request{Op: opGenerate, Summary: &summary, OutputDir: outputDir}
+	if err := g.roundTrip(req, &resp); err != nil {
+		return nil, fmt.Errorf("extplugin: %s: %w", opGenerate, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("extplugin: plugin reported error: %s", resp.Error)
+	}
+	return resp.Outputs, nil
+}
+
+// Close shuts down the plugin subprocess. If the plugin does not exit within
+// closeWait of its stdin being closed, Close kills it rather than letting it
+// hang the zither invocation indefinitely.
+func (g *Generator) Close() error {
+	g.in.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- g.cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(closeWait):
+		_ = g.cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("extplugin: plugin did not exit within %s of stdin closing; killed", closeWait)
+	}
+}
+
+func (g *Generator) roundTrip(req request, resp *response) error {
+	if err := g.enc.Encode(req); err != nil {
+		return err
+	}
+	return g.dec.Decode(resp)
+}
+
+type op string
+
+const (
+	opDeclOrder    op = "decl_order"
+	opDeclCallback op = "decl_callback"
+	opGenerate     op = "generate"
+)
+
+// declKind discriminates the concrete type underlying a zither.Decl sent in
+// a decl_callback request, since the interface itself carries none.
+type declKind string
+
+const (
+	declKindConst    declKind = "const"
+	declKindAlias    declKind = "alias"
+	declKindEnum     declKind = "enum"
+	declKindBits     declKind = "bits"
+	declKindStruct   declKind = "struct"
+	declKindProtocol declKind = "protocol"
+)
+
+// kindOf reports the declKind of decl, or an error if decl is none of
+// zither's known concrete decl types.
+func kindOf(decl zither.Decl) (declKind, error) {
+	switch decl.(type) {
+	case zither.Const:
+		return declKindConst, nil
+	case zither.Alias:
+		return declKindAlias, nil
+	case zither.Enum:
+		return declKindEnum, nil
+	case zither.Bits:
+		return declKindBits, nil
+	case zither.Struct:
+		return declKindStruct, nil
+	case zither.Protocol:
+		return declKindProtocol, nil
+	default:
+		return "", fmt.Errorf("decl_callback: unrecognized decl type %T", decl)
+	}
+}
+
+type request struct {
+	Op        op                     `json:"op"`
+	Kind      declKind               `json:"kind,omitempty"`
+	Decl      zither.Decl            `json:"decl,omitempty"`
+	Summary   *zither.LibrarySummary `json:"summary,omitempty"`
+	OutputDir string                 `json:"output_dir,omitempty"`
+}
+
+type response struct {
+	DeclOrder string   `json:"decl_order,omitempty"`
+	Outputs   []string `json:"outputs,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
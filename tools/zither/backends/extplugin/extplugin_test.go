@@ -0,0 +1,211 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package extplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+)
+
+// TestMain lets this same test binary stand in for a plugin subprocess: the
+// tests exec os.Args[0] (directly from loadHelper, or through the shim script
+// from writeShimScript when going through Load), and when
+// GO_WANT_HELPER_PLUGIN=1 is set in its environment, the process runs
+// helperPlugin instead of the test suite. This
+// avoids needing a separately built fixture executable.
+func TestMain(m *testing.M) {
+	if os.Getenv("GO_WANT_HELPER_PLUGIN") == "1" {
+		helperPlugin(os.Getenv("GO_WANT_HELPER_PLUGIN_MODE"))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// helperPlugin implements just enough of the extplugin protocol, over its
+// own stdin/stdout, to exercise Generator from the other end. mode selects
+// between well-behaved and misbehaving variants.
+func helperPlugin(mode string) {
+	if mode == "hang" {
+		// Answers the decl_order handshake, so that loadHelper can return,
+		// then never reads stdin to EOF and never exits on its own: exactly
+		// the misbehaving plugin Generator.Close's timeout exists to bound.
+		// It sleeps rather than blocking in an empty select, which the Go
+		// runtime would report as a deadlock and exit on.
+		var req request
+		if err := json.NewDecoder(bufio.NewReader(os.Stdin)).Decode(&req); err != nil {
+			return
+		}
+		json.NewEncoder(os.Stdout).Encode(response{DeclOrder: string(zither.SourceDeclOrder)})
+		for {
+			time.Sleep(time.Hour)
+		}
+	}
+	if mode == "crash_before_handshake" {
+		// Exits before ever answering decl_order: exactly the misbehaving
+		// plugin Load's kill-on-handshake-failure path exists to reap.
+		os.Exit(1)
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(os.Stdin))
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		switch req.Op {
+		case opDeclOrder:
+			enc.Encode(response{DeclOrder: string(zither.SourceDeclOrder)})
+		case opDeclCallback:
+			enc.Encode(response{})
+		case opGenerate:
+			if mode == "generate_error" {
+				enc.Encode(response{Error: "helper plugin: generate failed"})
+				continue
+			}
+			enc.Encode(response{Outputs: []string{"generated.txt"}})
+		}
+	}
+}
+
+func loadHelper(t *testing.T, mode string) *Generator {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMain$")
+	cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PLUGIN=1", "GO_WANT_HELPER_PLUGIN_MODE="+mode)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("StdinPipe: %s", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe: %s", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting helper plugin: %s", err)
+	}
+
+	g := &Generator{
+		cmd: cmd,
+		in:  stdin,
+		enc: json.NewEncoder(stdin),
+		dec: json.NewDecoder(bufio.NewReader(stdout)),
+	}
+	var resp response
+	if err := g.roundTrip(request{Op: opDeclOrder}, &resp); err != nil {
+		t.Fatalf("decl_order handshake: %s", err)
+	}
+	g.declOrder = zither.DeclOrder(resp.DeclOrder)
+	return g
+}
+
+// writeShimScript writes a small executable shell script that re-execs this
+// test binary as the helper plugin in the given mode, so that Load (which
+// only takes a bare executable path, with no room for the env vars
+// loadHelper sets directly) can be exercised against it end to end.
+func writeShimScript(t *testing.T, mode string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	script := fmt.Sprintf("#!/bin/sh\nexport GO_WANT_HELPER_PLUGIN=1\nexport GO_WANT_HELPER_PLUGIN_MODE=%s\nexec %q -test.run=^TestMain$\n", mode, os.Args[0])
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write shim script: %s", err)
+	}
+	return path
+}
+
+func TestLoadKillsProcessOnHandshakeFailure(t *testing.T) {
+	plugin := writeShimScript(t, "crash_before_handshake")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Load(plugin)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Load() against a plugin that crashes before the handshake: expected an error, got nil")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Load() against a crashing plugin did not return within 10s")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	cases := []struct {
+		decl zither.Decl
+		want declKind
+	}{
+		{zither.Const{}, declKindConst},
+		{zither.Alias{}, declKindAlias},
+		{zither.Enum{}, declKindEnum},
+		{zither.Bits{}, declKindBits},
+		{zither.Struct{}, declKindStruct},
+		{zither.Protocol{}, declKindProtocol},
+	}
+	for _, c := range cases {
+		got, err := kindOf(c.decl)
+		if err != nil {
+			t.Errorf("kindOf(%T) failed: %s", c.decl, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("kindOf(%T) = %q, want %q", c.decl, got, c.want)
+		}
+	}
+}
+
+func TestGeneratorRoundTrip(t *testing.T) {
+	g := loadHelper(t, "")
+	defer g.Close()
+
+	if got, want := g.DeclOrder(), zither.SourceDeclOrder; got != want {
+		t.Errorf("DeclOrder() = %q, want %q", got, want)
+	}
+
+	outputs, err := g.Generate(zither.LibrarySummary{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("Generate() failed: %s", err)
+	}
+	if want := []string{"generated.txt"}; len(outputs) != 1 || outputs[0] != want[0] {
+		t.Errorf("Generate() = %v, want %v", outputs, want)
+	}
+}
+
+func TestGeneratorGenerateError(t *testing.T) {
+	g := loadHelper(t, "generate_error")
+	defer g.Close()
+
+	if _, err := g.Generate(zither.LibrarySummary{}, t.TempDir()); err == nil {
+		t.Error("Generate() against an erroring plugin: expected an error, got nil")
+	}
+}
+
+func TestGeneratorCloseKillsHungPlugin(t *testing.T) {
+	g := loadHelper(t, "hang")
+
+	done := make(chan error, 1)
+	go func() { done <- g.Close() }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Close() against a hung plugin returned nil error, want a timeout error")
+		}
+	case <-time.After(closeWait + 10*time.Second):
+		t.Fatal("Close() against a hung plugin did not return within closeWait plus margin")
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package go_vdso_asm provides a zither backend that generates the Go
+// assembly stubs and declarations used by go.git's syscall/zx package to
+// call into the Fuchsia vDSO, replacing the hand-maintained output of the
+// historical mkfuchsia.go/abigen workflow.
+package go_vdso_asm
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"go.fuchsia.dev/fuchsia/tools/fidl/lib/fidlgen"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends"
+)
+
+func init() {
+	backends.Register("go_vdso_asm", func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return NewGenerator(f, ""), false
+	})
+}
+
+// Arch identifies a Go-flavored CPU architecture that the backend can target.
+type Arch string
+
+const (
+	ArchAMD64 Arch = "amd64"
+	ArchARM64 Arch = "arm64"
+)
+
+// SupportedArches are the Go architectures for which this backend knows how
+// to emit vDSO call stubs.
+var SupportedArches = []Arch{ArchAMD64, ArchARM64}
+
+// wordSize is the size, in bytes, zither assumes for every syscall
+// parameter and return value when laying out a Go stack frame: every
+// zx syscall parameter is either a scalar (handle, size_t, status, ...) or a
+// pointer, both of which are one machine word wide on the 64-bit arches this
+// backend targets. Aggregates passed or returned by value are out of scope.
+const wordSize = 8
+
+// argRegisters gives the ordered set of registers into which a stub loads
+// its Go-frame arguments before calling into the vDSO, per the relevant C
+// ABI: System V AMD64 for amd64, AAPCS64 for arm64. Arguments beyond this
+// count would need to be spilled to the C callee's stack, which this
+// backend does not yet support (no current zx syscall takes that many
+// arguments).
+var argRegisters = map[Arch][]string{
+	ArchAMD64: {"DI", "SI", "DX", "CX", "R8", "R9"},
+	ArchARM64: {"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7"},
+}
+
+// retRegisters gives the registers a vDSO call returns through: a single
+// scalar result in the first register, or a wide (128-bit) result split
+// across both.
+var retRegisters = map[Arch][]string{
+	ArchAMD64: {"AX", "DX"},
+	ArchARM64: {"R0", "R1"},
+}
+
+// movOp gives the mnemonic used to move a word-sized value on arch.
+var movOp = map[Arch]string{
+	ArchAMD64: "MOVQ",
+	ArchARM64: "MOVD",
+}
+
+// addrReg is the scratch register into which the vDSO call target's address
+// is loaded before the indirect call. It is deliberately disjoint from the
+// argument registers above.
+var addrReg = map[Arch]string{
+	ArchAMD64: "AX",
+	ArchARM64: "R16",
+}
+
+// callIndirect formats an indirect CALL through reg in arch's assembler
+// syntax: amd64 takes a bare register, while arm64 requires parens.
+func callIndirect(arch Arch, reg string) string {
+	if arch == ArchARM64 {
+		return fmt.Sprintf("CALL\t(%s)", reg)
+	}
+	return fmt.Sprintf("CALL\t%s", reg)
+}
+
+// Generator provides a zither backend that generates Go vDSO stub bindings
+// for the Fuchsia syscall ABI, as defined by FIDL `@transport("Syscall")`
+// protocols.
+type Generator struct {
+	fidlgen.Formatter
+
+	// arches is the set of architectures to emit stubs for. NewGenerator sets
+	// it to all of SupportedArches when no GOARCH is given; if it is empty, no
+	// assembly files are emitted.
+	arches []Arch
+
+	protocols []zither.Protocol
+}
+
+// NewGenerator creates a new go_vdso_asm backend generator. goarch, if
+// non-empty, restricts output to the single named architecture; otherwise
+// stubs are emitted for every architecture in SupportedArches.
+func NewGenerator(formatter fidlgen.Formatter, goarch string) *Generator {
+	gen := &Generator{Formatter: formatter}
+	if goarch == "" {
+		gen.arches = SupportedArches
+	} else {
+		gen.arches = []Arch{Arch(goarch)}
+	}
+	return gen
+}
+
+// SetGOArch restricts generation to the single named architecture. It is
+// meant to be called, if at all, before Generate - typically by cmd/main.go
+// plumbing through the `-goarch` flag, since the backends.Factory signature
+// has no room for backend-specific flags of its own.
+func (gen *Generator) SetGOArch(goarch string) {
+	if goarch != "" {
+		gen.arches = []Arch{Arch(goarch)}
+	}
+}
+
+// DeclOrder requests source declaration order so that syscalls remain
+// grouped by the protocol (i.e., syscall family) in which they were
+// declared, matching the grouping of the legacy abigen output.
+func (gen *Generator) DeclOrder() zither.DeclOrder {
+	return zither.SourceDeclOrder
+}
+
+// DeclCallback collects each syscall protocol as it is encountered.
+func (gen *Generator) DeclCallback(decl zither.Decl) {
+	if protocol, ok := decl.(zither.Protocol); ok {
+		gen.protocols = append(gen.protocols, protocol)
+	}
+}
+
+// Generate emits syscalls_fuchsia.go (the Go-level declarations) along with
+// one syscalls_fuchsia_$GOARCH.s file per requested architecture.
+func (gen *Generator) Generate(summary zither.LibrarySummary, outputDir string) ([]string, error) {
+	outputs := []string{}
+
+	declFile, err := gen.generateDecls(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	outputs = append(outputs, declFile)
+
+	for _, arch := range gen.arches {
+		asmFile, err := gen.generateAsm(arch, outputDir)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, asmFile)
+	}
+
+	return outputs, nil
+}
+
+// OutputUnits reports one unit for the shared Go declarations plus one unit
+// per requested architecture's assembly file, letting cmd/main.go's default
+// parallel adapter generate them concurrently under `-jobs`.
+func (gen *Generator) OutputUnits(zither.LibrarySummary) int {
+	return 1 + len(gen.arches)
+}
+
+// GenerateUnit generates unit i, as numbered by OutputUnits: unit 0 is the
+// shared syscalls_fuchsia.go declarations, and unit i (i >= 1) is the
+// assembly file for gen.arches[i-1].
+func (gen *Generator) GenerateUnit(_ zither.LibrarySummary, outputDir string, i int) ([]string, error) {
+	if i == 0 {
+		declFile, err := gen.generateDecls(outputDir)
+		if err != nil {
+			return nil, err
+		}
+		return []string{declFile}, nil
+	}
+	asmFile, err := gen.generateAsm(gen.arches[i-1], outputDir)
+	if err != nil {
+		return nil, err
+	}
+	return []string{asmFile}, nil
+}
+
+func (gen *Generator) generateDecls(outputDir string) (string, error) {
+	const declFile = "syscalls_fuchsia.go"
+
+	var declText strings.Builder
+	if err := declTemplate.Execute(&declText, gen.protocols); err != nil {
+		return "", err
+	}
+	formatted, err := gen.Format([]byte(declText.String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to format %s: %w", declFile, err)
+	}
+	if err := zither.WriteFile(filepath.Join(outputDir, declFile), formatted); err != nil {
+		return "", err
+	}
+	return declFile, nil
+}
+
+func (gen *Generator) generateAsm(arch Arch, outputDir string) (string, error) {
+	asmFile := fmt.Sprintf("syscalls_fuchsia_%s.s", arch)
+
+	methods, err := methodAsmsFor(arch, gen.protocols)
+	if err != nil {
+		return "", err
+	}
+
+	var asmText strings.Builder
+	if err := asmTemplate.Execute(&asmText, struct {
+		Arch     Arch
+		MovOp    string
+		AddrReg  string
+		CallAddr string
+		Methods  []methodAsm
+	}{arch, movOp[arch], addrReg[arch], callIndirect(arch, addrReg[arch]), methods}); err != nil {
+		return "", err
+	}
+	if err := zither.WriteFile(filepath.Join(outputDir, asmFile), []byte(asmText.String())); err != nil {
+		return "", err
+	}
+	return asmFile, nil
+}
+
+// regArg is a single argument or return value, together with where it lives
+// in the Go stack frame and which register it is marshaled through when
+// calling into the vDSO.
+type regArg struct {
+	Name   string
+	Offset int64
+	Reg    string
+}
+
+// methodAsm is the fully resolved, arch-specific layout of one syscall stub:
+// everything the asm template needs to emit argument loads, the call, and
+// result stores, without doing arithmetic in the template itself.
+type methodAsm struct {
+	Name      string
+	Args      []regArg
+	Rets      []regArg
+	FrameSize int64
+}
+
+// wordSizedGoTypes enumerates the Go type names this backend's declTemplate
+// can emit for a syscall parameter or return value that are actually
+// wordSize bytes wide - the only layout methodAsmsFor's offset arithmetic
+// below is sound for. A type outside this set (a bool, int32, or any other
+// sub-word scalar) would silently land at the wrong FP offset in the
+// emitted .s file if allowed through uncaught, per the backend's own
+// documented "one machine word wide" assumption - so checkWordSized rejects
+// it instead of miscompiling it.
+var wordSizedGoTypes = map[string]bool{
+	"int64":          true,
+	"uint64":         true,
+	"uintptr":        true,
+	"float64":        true,
+	"unsafe.Pointer": true,
+}
+
+// checkWordSized reports an error if typ is not one of wordSizedGoTypes, or
+// a pointer type (which is always word-sized on the 64-bit arches this
+// backend targets, regardless of pointee).
+func checkWordSized(name, typ string) error {
+	if strings.HasPrefix(typ, "*") || wordSizedGoTypes[typ] {
+		return nil
+	}
+	return fmt.Errorf("go_vdso_asm: %s: parameter/return of type %q is not word-sized; only pointer types and %v are supported", name, typ, sortedKeys(wordSizedGoTypes))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// methodAsmsFor resolves every method of every protocol into its arch-
+// specific register/offset layout.
+func methodAsmsFor(arch Arch, protocols []zither.Protocol) ([]methodAsm, error) {
+	argRegs := argRegisters[arch]
+	retRegs := retRegisters[arch]
+
+	var methods []methodAsm
+	for _, protocol := range protocols {
+		for _, m := range protocol.Methods {
+			if len(m.Parameters) > len(argRegs) {
+				return nil, fmt.Errorf("go_vdso_asm: %s: %d parameters exceeds the %d argument registers supported for %s", m.Name, len(m.Parameters), len(argRegs), arch)
+			}
+			if len(m.ReturnParameters) > len(retRegs) {
+				return nil, fmt.Errorf("go_vdso_asm: %s: %d return values exceeds the %d return registers supported for %s", m.Name, len(m.ReturnParameters), len(retRegs), arch)
+			}
+
+			var offset int64
+			args := make([]regArg, len(m.Parameters))
+			for i, p := range m.Parameters {
+				if err := checkWordSized(m.Name+"."+p.Name, p.Type); err != nil {
+					return nil, err
+				}
+				args[i] = regArg{Name: p.Name, Offset: offset, Reg: argRegs[i]}
+				offset += wordSize
+			}
+			rets := make([]regArg, len(m.ReturnParameters))
+			for i, r := range m.ReturnParameters {
+				if err := checkWordSized(m.Name+"."+r.Name, r.Type); err != nil {
+					return nil, err
+				}
+				rets[i] = regArg{Name: r.Name, Offset: offset, Reg: retRegs[i]}
+				offset += wordSize
+			}
+
+			methods = append(methods, methodAsm{
+				Name:      m.Name,
+				Args:      args,
+				Rets:      rets,
+				FrameSize: offset,
+			})
+		}
+	}
+	return methods, nil
+}
+
+var declTemplate = template.Must(template.New("syscallsFuchsiaGo").Parse(`// Copyright 2024 The Go Authors. All rights reserved.
+// Generated by zither (go_vdso_asm backend); DO NOT EDIT.
+
+package zx
+
+{{range .}}
+{{range .Methods}}
+//go:noescape
+func sys_{{.Name}}({{range .Parameters}}{{.Name}} {{.Type}}, {{end}}) ({{range .ReturnParameters}}{{.Name}} {{.Type}}, {{end}})
+{{end}}
+{{- end}}
+`))
+
+// asmTemplate emits one TEXT stub per method: it loads each Go-frame
+// argument into the register the target ABI (System V AMD64 or AAPCS64)
+// expects it in, calls into the vDSO, and stores each result register back
+// into its Go-frame return slot. The `$0-N` frame declaration's N is the
+// resolved argument+return size, so that `go vet`'s asmdecl check can
+// confirm it against the corresponding sys_* declaration.
+var asmTemplate = template.Must(template.New("syscallsFuchsiaAsm").Parse(`// Copyright 2024 The Go Authors. All rights reserved.
+// Generated by zither (go_vdso_asm backend); DO NOT EDIT.
+
+#include "textflag.h"
+
+{{$movOp := .MovOp}}
+{{$addrReg := .AddrReg}}
+{{$callAddr := .CallAddr}}
+{{range .Methods}}
+TEXT ·sys_{{.Name}}(SB),NOSPLIT,$0-{{.FrameSize}}
+	CALL	runtime·entersyscall(SB)
+{{range .Args}}	{{$movOp}}	{{.Name}}+{{.Offset}}(FP), {{.Reg}}
+{{end -}}
+	{{$movOp}}	vdso_{{.Name}}_addr(SB), {{$addrReg}}
+	{{$callAddr}}
+{{range .Rets}}	{{$movOp}}	{{.Reg}}, {{.Name}}+{{.Offset}}(FP)
+{{end -}}
+	CALL	runtime·exitsyscall(SB)
+	RET
+{{end}}
+`))
@@ -0,0 +1,125 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package go_vdso_asm
+
+import (
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+)
+
+func protocolWith(methods ...zither.Method) []zither.Protocol {
+	return []zither.Protocol{{Methods: methods}}
+}
+
+func TestMethodAsmsForOffsetsAndRegisters(t *testing.T) {
+	protocols := protocolWith(zither.Method{
+		Name: "channel_write",
+		Parameters: []zither.Parameter{
+			{Name: "handle", Type: "uint64"},
+			{Name: "data", Type: "unsafe.Pointer"},
+			{Name: "num_bytes", Type: "uint64"},
+		},
+		ReturnParameters: []zither.Parameter{
+			{Name: "status", Type: "int64"},
+		},
+	})
+
+	methods, err := methodAsmsFor(ArchAMD64, protocols)
+	if err != nil {
+		t.Fatalf("methodAsmsFor() failed: %s", err)
+	}
+	if len(methods) != 1 {
+		t.Fatalf("methodAsmsFor() returned %d methods, want 1", len(methods))
+	}
+
+	got := methods[0]
+	wantArgs := []regArg{
+		{Name: "handle", Offset: 0, Reg: "DI"},
+		{Name: "data", Offset: 8, Reg: "SI"},
+		{Name: "num_bytes", Offset: 16, Reg: "DX"},
+	}
+	if !reflect.DeepEqual(got.Args, wantArgs) {
+		t.Errorf("Args = %#v, want %#v", got.Args, wantArgs)
+	}
+
+	wantRets := []regArg{
+		{Name: "status", Offset: 24, Reg: "AX"},
+	}
+	if !reflect.DeepEqual(got.Rets, wantRets) {
+		t.Errorf("Rets = %#v, want %#v", got.Rets, wantRets)
+	}
+
+	if got.FrameSize != 32 {
+		t.Errorf("FrameSize = %d, want 32", got.FrameSize)
+	}
+}
+
+func TestMethodAsmsForARM64Registers(t *testing.T) {
+	protocols := protocolWith(zither.Method{
+		Name: "clock_get",
+		Parameters: []zither.Parameter{
+			{Name: "clock_id", Type: "uint64"},
+		},
+		ReturnParameters: []zither.Parameter{
+			{Name: "out", Type: "*int64"},
+		},
+	})
+
+	methods, err := methodAsmsFor(ArchARM64, protocols)
+	if err != nil {
+		t.Fatalf("methodAsmsFor() failed: %s", err)
+	}
+
+	got := methods[0]
+	if got.Args[0].Reg != "R0" {
+		t.Errorf("Args[0].Reg = %q, want R0", got.Args[0].Reg)
+	}
+	if got.Rets[0].Reg != "R0" {
+		t.Errorf("Rets[0].Reg = %q, want R0", got.Rets[0].Reg)
+	}
+	if got.Rets[0].Offset != wordSize {
+		t.Errorf("Rets[0].Offset = %d, want %d", got.Rets[0].Offset, wordSize)
+	}
+}
+
+func TestMethodAsmsForTooManyParameters(t *testing.T) {
+	params := make([]zither.Parameter, len(argRegisters[ArchAMD64])+1)
+	for i := range params {
+		params[i] = zither.Parameter{Name: "p", Type: "uint64"}
+	}
+	protocols := protocolWith(zither.Method{Name: "too_many", Parameters: params})
+
+	if _, err := methodAsmsFor(ArchAMD64, protocols); err == nil {
+		t.Error("methodAsmsFor() with too many parameters: expected an error, got nil")
+	}
+}
+
+func TestMethodAsmsForRejectsSubWordTypes(t *testing.T) {
+	for _, typ := range []string{"bool", "int32", "uint32", "int16", "int8"} {
+		protocols := protocolWith(zither.Method{
+			Name: "bad_type",
+			Parameters: []zither.Parameter{
+				{Name: "flag", Type: typ},
+			},
+		})
+		if _, err := methodAsmsFor(ArchAMD64, protocols); err == nil {
+			t.Errorf("methodAsmsFor() with a %s parameter: expected an error, got nil", typ)
+		}
+	}
+}
+
+func TestMethodAsmsForAcceptsPointerTypes(t *testing.T) {
+	protocols := protocolWith(zither.Method{
+		Name: "takes_pointer",
+		Parameters: []zither.Parameter{
+			{Name: "out", Type: "*uint32"},
+		},
+	})
+	if _, err := methodAsmsFor(ArchAMD64, protocols); err != nil {
+		t.Errorf("methodAsmsFor() with a pointer parameter failed: %s", err)
+	}
+}
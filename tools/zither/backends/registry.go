@@ -0,0 +1,79 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package backends is a registry of zither backends, keyed by the name
+// passed to `-backend`. A backend registers itself by calling Register from
+// an init() function in its own package; cmd/main.go then looks backends up
+// by name rather than hard-coding a switch over every known backend. This
+// lets out-of-tree backends - e.g. ones loaded via `-backend-plugin` - be
+// added without patching cmd/main.go.
+package backends
+
+import (
+	"sort"
+	"sync"
+
+	"go.fuchsia.dev/fuchsia/tools/fidl/lib/fidlgen"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+)
+
+// Generator represents an abstract generator of bindings. It is the
+// registry's view of cmd/main.go's generator interface, which any real
+// backend already satisfies structurally.
+type Generator interface {
+	// DeclOrder gives the declaration order desired by the backend.
+	DeclOrder() zither.DeclOrder
+
+	// DeclCallback is a callback intended to be passed to zither.Summarize(),
+	// called on each Decl in the requested declaration order.
+	DeclCallback(zither.Decl)
+
+	// Generate generates bindings into the provided output directory,
+	// returning the list of outputs emitted.
+	Generate(summary zither.LibrarySummary, outputDir string) ([]string, error)
+}
+
+// Factory constructs a backend's Generator given a formatter and an
+// `-output-namespace` value. The returned bool reports whether the backend
+// honors a namespace override at all; callers are expected to reject a
+// non-empty `-output-namespace` when it is false.
+type Factory func(f fidlgen.Formatter, namespace string) (Generator, bool)
+
+var (
+	mu       sync.Mutex
+	registry = make(map[string]Factory)
+)
+
+// Register registers factory under name, so that it can subsequently be
+// looked up by Lookup. It is expected to be called from the registering
+// backend's init() function. Register panics if name is already registered,
+// as that indicates two backends colliding on the same `-backend` value.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("backends: duplicate registration for " + name)
+	}
+	registry[name] = factory
+}
+
+// Lookup returns the factory registered under name, if any.
+func Lookup(name string) (Factory, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	factory, ok := registry[name]
+	return factory, ok
+}
+
+// Names returns the names of every registered backend, sorted.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
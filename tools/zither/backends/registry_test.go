@@ -0,0 +1,90 @@
+// Copyright 2024 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package backends
+
+import (
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/fuchsia/tools/fidl/lib/fidlgen"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+)
+
+type fakeGenerator struct{}
+
+func (fakeGenerator) DeclOrder() zither.DeclOrder { return zither.SourceDeclOrder }
+func (fakeGenerator) DeclCallback(zither.Decl)     {}
+func (fakeGenerator) Generate(zither.LibrarySummary, string) ([]string, error) {
+	return nil, nil
+}
+
+func withCleanRegistry(t *testing.T) {
+	t.Helper()
+	mu.Lock()
+	saved := registry
+	registry = make(map[string]Factory)
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		mu.Lock()
+		registry = saved
+		mu.Unlock()
+	})
+}
+
+func TestRegisterAndLookup(t *testing.T) {
+	withCleanRegistry(t)
+
+	factory := func(fidlgen.Formatter, string) (Generator, bool) { return fakeGenerator{}, true }
+	Register("fake", factory)
+
+	got, ok := Lookup("fake")
+	if !ok {
+		t.Fatalf(`Lookup("fake") reported not found after Register`)
+	}
+	gen, namespaceable := got(nil, "")
+	if !namespaceable {
+		t.Errorf("factory returned by Lookup reported namespaceable = false, want true")
+	}
+	if _, ok := gen.(fakeGenerator); !ok {
+		t.Errorf("factory returned by Lookup produced a %T, want fakeGenerator", gen)
+	}
+}
+
+func TestLookupMissing(t *testing.T) {
+	withCleanRegistry(t)
+
+	if _, ok := Lookup("does_not_exist"); ok {
+		t.Error(`Lookup("does_not_exist") reported found, want not found`)
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	withCleanRegistry(t)
+
+	factory := func(fidlgen.Formatter, string) (Generator, bool) { return fakeGenerator{}, false }
+	Register("fake", factory)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Register of a duplicate name did not panic")
+		}
+	}()
+	Register("fake", factory)
+}
+
+func TestNames(t *testing.T) {
+	withCleanRegistry(t)
+
+	factory := func(fidlgen.Formatter, string) (Generator, bool) { return fakeGenerator{}, false }
+	Register("zebra", factory)
+	Register("apple", factory)
+	Register("mango", factory)
+
+	want := []string{"apple", "mango", "zebra"}
+	if got := Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
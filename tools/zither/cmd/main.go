@@ -8,17 +8,26 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"go.fuchsia.dev/fuchsia/tools/fidl/lib/fidlgen"
 	"go.fuchsia.dev/fuchsia/tools/lib/color"
 	"go.fuchsia.dev/fuchsia/tools/lib/flagmisc"
 	"go.fuchsia.dev/fuchsia/tools/lib/logger"
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither/abicheck"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends"
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/asm"
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/c"
+	_ "go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/cpp"
+	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/extplugin"
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/go_runtime"
+	_ "go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/go_vdso_asm"
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/golang"
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/kernel"
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/legacy_syscall_cdecl"
@@ -28,8 +37,55 @@ import (
 	"go.fuchsia.dev/fuchsia/zircon/tools/zither/backends/zircon_ifs"
 )
 
+// init registers the backends that have not yet migrated to self-registering
+// via backends.Register in their own init() (as the cpp and go_vdso_asm
+// backends do); it bridges them into the same registry so that cmd/main.go
+// has a single lookup path regardless of backend vintage.
+func init() {
+	backends.Register(cBackend, func(f fidlgen.Formatter, namespace string) (backends.Generator, bool) {
+		return c.NewGenerator(f, namespace), true
+	})
+	backends.Register(asmBackend, func(f fidlgen.Formatter, namespace string) (backends.Generator, bool) {
+		return asm.NewGenerator(f, namespace), true
+	})
+	backends.Register(goBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return golang.NewGenerator(f), false
+	})
+	backends.Register(rustBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return rust.NewGenerator(f), false
+	})
+	backends.Register(zirconIFSBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return zircon_ifs.NewGenerator(f), false
+	})
+	backends.Register(kernelBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return kernel.NewGenerator(f), false
+	})
+	backends.Register(legacySyscallCDeclBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return legacy_syscall_cdecl.NewGenerator(f), false
+	})
+	backends.Register(rustSyscallBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return rust_syscall.NewGenerator(f), false
+	})
+	backends.Register(goRuntimeBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return go_runtime.NewGenerator(f), false
+	})
+	backends.Register(syscallDocsBackend, func(f fidlgen.Formatter, _ string) (backends.Generator, bool) {
+		return syscall_docs.NewGenerator(f), false
+	})
+}
+
+const (
+	// generateMode is the default mode: generate bindings for `-backend`.
+	generateMode string = "generate"
+
+	// verifyMode compares the ABI implied by `-ir` against a previously
+	// generated `-baseline-ifs` file, exiting non-zero on breaking changes.
+	verifyMode string = "verify"
+)
+
 const (
 	cBackend                  string = "c"
+	cppBackend                string = "cpp"
 	goBackend                 string = "go"
 	asmBackend                string = "asm"
 	rustBackend               string = "rust"
@@ -39,10 +95,12 @@ const (
 	rustSyscallBackend        string = "rust_syscall"
 	goRuntimeBackend          string = "go_runtime"
 	syscallDocsBackend        string = "syscall_docs"
+	goVDSOAsmBackend          string = "go_vdso_asm"
 )
 
 var supportedBackends = []string{
 	cBackend,
+	cppBackend,
 	goBackend,
 	asmBackend,
 	rustBackend,
@@ -52,6 +110,7 @@ var supportedBackends = []string{
 	rustSyscallBackend,
 	goRuntimeBackend,
 	syscallDocsBackend,
+	goVDSOAsmBackend,
 }
 
 // Flag values, grouped into a struct to be kept out of the global namespace.
@@ -64,6 +123,11 @@ var flags struct {
 	sourceDir       string
 	formatter       string
 	formatterArgs   flagmisc.StringsValue
+	goarch          string
+	jobs            int
+	mode            string
+	baselineIFS     string
+	backendPlugin   string
 }
 
 func init() {
@@ -98,6 +162,29 @@ took place`)
 
 	flag.Var(&flags.formatterArgs, "formatter-args",
 		`Arguments to pass to the formatter`)
+
+	flag.StringVar(&flags.goarch, "goarch", "",
+		`The GOARCH to emit vDSO call stubs for (only meaningful for the `+goVDSOAsmBackend+` backend).
+If unset, stubs for every supported architecture are emitted.`)
+
+	flag.IntVar(&flags.jobs, "jobs", runtime.NumCPU(),
+		`The number of workers to use when generating bindings in parallel. A value of 1 disables
+parallelism, generating output serially and deterministically; this is the mode golden tests should use.`)
+
+	flag.StringVar(&flags.mode, "mode", generateMode,
+		`The zither mode. Supported options:
+* `+generateMode+`: generate bindings for the backend given by -backend (the default)
+* `+verifyMode+`: compare the ABI implied by -ir against -baseline-ifs, exiting non-zero on breaking changes;
+  in this mode, -output-manifest receives the JSON diff report rather than a list of output files`)
+
+	flag.StringVar(&flags.baselineIFS, "baseline-ifs", "",
+		`In -mode=`+verifyMode+`, the path to the previously generated .ifs file to compare -ir against. Required
+in that mode`)
+
+	flag.StringVar(&flags.backendPlugin, "backend-plugin", "",
+		`The path to an out-of-tree backend, implementing the extplugin protocol (see
+//zircon/tools/zither/backends/extplugin). If set, this takes the place of -backend and bindings are
+generated by the plugin subprocess instead of a built-in backend`)
 }
 
 func main() {
@@ -110,8 +197,26 @@ func main() {
 		logger.Errorf(ctx, "`-ir` is a required argument")
 		os.Exit(1)
 	}
-	if flags.backend == "" {
-		logger.Errorf(ctx, "`-backend` is a required argument")
+
+	if flags.mode == verifyMode {
+		if flags.baselineIFS == "" {
+			logger.Errorf(ctx, "`-baseline-ifs` is required in `-mode=%s`", verifyMode)
+			os.Exit(1)
+		}
+		breaking, err := runVerify(ctx, flags.irFile, flags.sourceDir, flags.baselineIFS, flags.outputManifest)
+		if err != nil {
+			logger.Errorf(ctx, "%s", err)
+			os.Exit(1)
+		}
+		if breaking {
+			logger.Errorf(ctx, "breaking ABI changes detected relative to %s", flags.baselineIFS)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flags.backend == "" && flags.backendPlugin == "" {
+		logger.Errorf(ctx, "one of `-backend` or `-backend-plugin` is required")
 		os.Exit(1)
 	}
 	if flags.outputDir == "" {
@@ -123,48 +228,35 @@ func main() {
 		}
 	}
 
-	// Not every backend supports an output namespace override.
-	assertNoNamespace := func() {
-		if flags.outputNamespace != "" {
+	var gen generator
+	if flags.backendPlugin != "" {
+		plugin, err := extplugin.Load(flags.backendPlugin)
+		if err != nil {
+			logger.Errorf(ctx, "%s", err)
+			os.Exit(1)
+		}
+		defer plugin.Close()
+		gen = plugin
+	} else {
+		factory, ok := backends.Lookup(flags.backend)
+		if !ok {
+			logger.Errorf(ctx, "unrecognized `-backend` value: %q", flags.backend)
+			os.Exit(1)
+		}
+		f := fidlgen.NewFormatter(flags.formatter, flags.formatterArgs...)
+		g, namespaceable := factory(f, flags.outputNamespace)
+		if !namespaceable && flags.outputNamespace != "" {
 			logger.Errorf(ctx, "backend %q does not support an `-output-namespace` override (%q)", flags.backend, flags.outputNamespace)
 			os.Exit(1)
 		}
+		gen = g
 	}
 
-	f := fidlgen.NewFormatter(flags.formatter, flags.formatterArgs...)
-	var gen generator
-	switch flags.backend {
-	case cBackend:
-		gen = c.NewGenerator(f, flags.outputNamespace)
-	case asmBackend:
-		gen = asm.NewGenerator(f, flags.outputNamespace)
-	case goBackend:
-		assertNoNamespace()
-		gen = golang.NewGenerator(f)
-	case rustBackend:
-		assertNoNamespace()
-		gen = rust.NewGenerator(f)
-	case zirconIFSBackend:
-		assertNoNamespace()
-		gen = zircon_ifs.NewGenerator(f)
-	case kernelBackend:
-		assertNoNamespace()
-		gen = kernel.NewGenerator(f)
-	case legacySyscallCDeclBackend:
-		assertNoNamespace()
-		gen = legacy_syscall_cdecl.NewGenerator(f)
-	case rustSyscallBackend:
-		assertNoNamespace()
-		gen = rust_syscall.NewGenerator(f)
-	case goRuntimeBackend:
-		assertNoNamespace()
-		gen = go_runtime.NewGenerator(f)
-	case syscallDocsBackend:
-		assertNoNamespace()
-		gen = syscall_docs.NewGenerator(f)
-	default:
-		logger.Errorf(ctx, "unrecognized `-backend` value: %q", flags.backend)
-		os.Exit(1)
+	// A handful of backends take additional, backend-specific configuration
+	// that doesn't fit the shared backends.Factory signature; they opt into
+	// it via an optional interface instead.
+	if setter, ok := gen.(goarchSetter); ok {
+		setter.SetGOArch(flags.goarch)
 	}
 
 	ir, err := fidlgen.ReadJSONIr(flags.irFile)
@@ -173,7 +265,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := execute(ctx, gen, ir, flags.sourceDir, flags.outputDir, flags.outputManifest); err != nil {
+	jobs := flags.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	if err := execute(ctx, gen, ir, flags.sourceDir, flags.outputDir, flags.outputManifest, jobs); err != nil {
 		logger.Errorf(ctx, "%s", err)
 		os.Exit(1)
 	}
@@ -195,13 +292,207 @@ type generator interface {
 	Generate(summary zither.LibrarySummary, outputDir string) ([]string, error)
 }
 
-func execute(ctx context.Context, gen generator, ir fidlgen.Root, sourceDir, outputDir, outputManifest string) error {
+// goarchSetter is implemented by backends (namely go_vdso_asm) whose output
+// is scoped to a single GOARCH via the `-goarch` flag.
+type goarchSetter interface {
+	SetGOArch(goarch string)
+}
+
+// parallelGenerator is implemented by backends that can generate their
+// output (typically one file per declaration) across a bounded pool of
+// workers, rather than serially within a single call to Generate.
+type parallelGenerator interface {
+	generator
+
+	// GenerateParallel generates bindings into the provided output
+	// directory using up to jobs concurrent workers, returning the list of
+	// outputs emitted. The returned order need not be deterministic; callers
+	// that require deterministic output (e.g. golden tests) should pass
+	// jobs=1, for which implementations are expected to fall back to their
+	// serial behavior.
+	GenerateParallel(summary zither.LibrarySummary, outputDir string, jobs int) ([]string, error)
+}
+
+// declGenerator is implemented by backends that can emit their output as a
+// set of independent units (one per declaration, one per output file,
+// etc.), without needing to write their own worker pool: the default
+// parallel adapter below fans these out across runWorkerPool, so that
+// `-jobs` has a real effect for any backend that implements this interface.
+type declGenerator interface {
+	generator
+
+	// OutputUnits returns the number of independent units of output
+	// Generate would produce for summary.
+	OutputUnits(summary zither.LibrarySummary) int
+
+	// GenerateUnit generates the i'th unit (0 <= i < OutputUnits(summary))
+	// into outputDir, returning the outputs it emitted. It must be safe to
+	// call concurrently across distinct values of i.
+	GenerateUnit(summary zither.LibrarySummary, outputDir string, i int) ([]string, error)
+}
+
+// generateParallel dispatches to the most specific parallel strategy gen
+// supports, falling back to a single serial call to Generate: a backend
+// implementing parallelGenerator gets full control; one implementing
+// declGenerator gets its units fanned out across runWorkerPool; otherwise
+// `-jobs` has no effect on that backend.
+func generateParallel(gen generator, summary zither.LibrarySummary, outputDir string, jobs int) ([]string, error) {
+	if jobs <= 1 {
+		return gen.Generate(summary, outputDir)
+	}
+	if pgen, ok := gen.(parallelGenerator); ok {
+		return pgen.GenerateParallel(summary, outputDir, jobs)
+	}
+	if dgen, ok := gen.(declGenerator); ok {
+		n := dgen.OutputUnits(summary)
+		return runWorkerPool(n, jobs, func(i int) ([]string, error) {
+			return dgen.GenerateUnit(summary, outputDir, i)
+		})
+	}
+	return gen.Generate(summary, outputDir)
+}
+
+// runWorkerPool runs fn(i) for each i in [0, n) across up to jobs concurrent
+// workers, collecting the resulting outputs (or the first error encountered)
+// across all of them. With jobs == 1, tasks run serially, in order -
+// preserving the deterministic output that golden tests rely on.
+func runWorkerPool(n, jobs int, fn func(i int) ([]string, error)) ([]string, error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs == 1 {
+		var outputs []string
+		for i := 0; i < n; i++ {
+			out, err := fn(i)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, out...)
+		}
+		return outputs, nil
+	}
+
+	type result struct {
+		i       int
+		outputs []string
+		err     error
+	}
+
+	tasks := make(chan int)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				out, err := fn(i)
+				results <- result{i: i, outputs: out, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			tasks <- i
+		}
+		close(tasks)
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	ordered := make([][]string, n)
+	var firstErr error
+	for res := range results {
+		if res.err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("task %d: %w", res.i, res.err)
+			continue
+		}
+		ordered[res.i] = res.outputs
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	var outputs []string
+	for _, out := range ordered {
+		outputs = append(outputs, out...)
+	}
+	return outputs, nil
+}
+
+// runVerify generates the .ifs implied by the FIDL IR at irFile and compares
+// it against the previously generated .ifs file at baselineIFS, writing a
+// structured abicheck.Report to outputManifest (if given) and returning
+// whether a breaking ABI change was found.
+func runVerify(ctx context.Context, irFile, sourceDir, baselineIFS, outputManifest string) (bool, error) {
+	ir, err := fidlgen.ReadJSONIr(irFile)
+	if err != nil {
+		return false, err
+	}
+
+	gen := zircon_ifs.NewGenerator(fidlgen.NewFormatter("" /* formatter */))
+	summary, err := zither.Summarize(ir, sourceDir, gen.DeclOrder(), gen.DeclCallback)
+	if err != nil {
+		return false, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "zither-abi-check")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	outputs, err := gen.Generate(*summary, tmpDir)
+	if err != nil {
+		return false, err
+	}
+	if len(outputs) != 1 {
+		return false, fmt.Errorf("expected zircon_ifs backend to emit exactly one file, got %d", len(outputs))
+	}
+
+	current, err := abicheck.ParseIFS(filepath.Join(tmpDir, outputs[0]))
+	if err != nil {
+		return false, err
+	}
+	baseline, err := abicheck.ParseIFS(baselineIFS)
+	if err != nil {
+		return false, err
+	}
+
+	report := abicheck.Compare(baseline, current)
+	if outputManifest != "" {
+		f, err := os.Create(outputManifest)
+		if err != nil {
+			return false, err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "\t")
+		if err := encoder.Encode(report); err != nil {
+			f.Close()
+			return false, err
+		}
+		if err := f.Close(); err != nil {
+			return false, err
+		}
+	}
+
+	for _, change := range report.Changes {
+		logger.Infof(ctx, "%s: %s", change.Kind, change.Name)
+	}
+
+	return report.Breaking, nil
+}
+
+func execute(ctx context.Context, gen generator, ir fidlgen.Root, sourceDir, outputDir, outputManifest string, jobs int) error {
 	summary, err := zither.Summarize(ir, sourceDir, gen.DeclOrder(), gen.DeclCallback)
 	if err != nil {
 		return err
 	}
 
-	outputs, err := gen.Generate(*summary, outputDir)
+	outputs, err := generateParallel(gen, *summary, outputDir, jobs)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,119 @@
+// Copyright 2022 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunWorkerPoolSerial(t *testing.T) {
+	var order []int
+	outputs, err := runWorkerPool(5, 1, func(i int) ([]string, error) {
+		order = append(order, i)
+		return []string{fmt.Sprintf("out-%d", i)}, nil
+	})
+	if err != nil {
+		t.Fatalf("runWorkerPool() failed: %s", err)
+	}
+
+	wantOrder := []int{0, 1, 2, 3, 4}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("call order = %v, want %v (in-order for jobs=1)", order, wantOrder)
+	}
+
+	want := []string{"out-0", "out-1", "out-2", "out-3", "out-4"}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("outputs = %v, want %v", outputs, want)
+	}
+}
+
+func TestRunWorkerPoolParallelPreservesUnitOrder(t *testing.T) {
+	const n = 50
+	outputs, err := runWorkerPool(n, 8, func(i int) ([]string, error) {
+		return []string{fmt.Sprintf("out-%d", i)}, nil
+	})
+	if err != nil {
+		t.Fatalf("runWorkerPool() failed: %s", err)
+	}
+
+	want := make([]string, n)
+	for i := range want {
+		want[i] = fmt.Sprintf("out-%d", i)
+	}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("outputs = %v, want %v (output order should follow unit index, not completion order)", outputs, want)
+	}
+}
+
+func TestRunWorkerPoolParallelUsesBoundedConcurrency(t *testing.T) {
+	const jobs = 4
+	var inFlight, maxInFlight int32
+	release := make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		// Twice as many tasks as workers, so that exceeding the bound would
+		// be observable.
+		runWorkerPool(2*jobs, jobs, func(i int) ([]string, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				old := atomic.LoadInt32(&maxInFlight)
+				if n <= old || atomic.CompareAndSwapInt32(&maxInFlight, old, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(&inFlight, -1)
+			return nil, nil
+		})
+		close(done)
+	}()
+
+	// Give every worker a chance to start before releasing them.
+	deadline := time.After(5 * time.Second)
+	for atomic.LoadInt32(&inFlight) < jobs {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for all workers to start")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	close(release)
+	<-done
+
+	if maxInFlight != jobs {
+		t.Errorf("max concurrent workers observed = %d, want %d", maxInFlight, jobs)
+	}
+}
+
+func TestRunWorkerPoolErrorAggregation(t *testing.T) {
+	wantErr := fmt.Errorf("boom")
+	_, err := runWorkerPool(3, 3, func(i int) ([]string, error) {
+		if i == 1 {
+			return nil, wantErr
+		}
+		return []string{fmt.Sprintf("out-%d", i)}, nil
+	})
+	if err == nil {
+		t.Fatal("runWorkerPool() with a failing task: expected an error, got nil")
+	}
+}
+
+func TestRunWorkerPoolZeroUnits(t *testing.T) {
+	outputs, err := runWorkerPool(0, 4, func(i int) ([]string, error) {
+		t.Fatalf("fn called for n=0")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("runWorkerPool(0, ...) failed: %s", err)
+	}
+	if len(outputs) != 0 {
+		t.Errorf("outputs = %v, want empty", outputs)
+	}
+}